@@ -24,10 +24,18 @@ import (
 	"go/parser"
 	"go/printer"
 	"go/token"
+	"go/types"
 	"io"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/packages"
 )
 
 var _ flag.Value = (*flagStringSet)(nil)
@@ -57,29 +65,47 @@ type flagOpts struct {
 	definitionFile              string
 	outFile                     string
 	structTypeName              string
+	packageDir                  string
+	structTypePattern           string
+	style                       string
 	exportFnType                bool
 	generateForUnexportedFields bool
 	ignoreUnsupported           bool
+	promoteEmbedded             bool
 	skipStructFields            flagStringSet
 }
 
 func getFlags() *flagOpts {
 	opts := new(flagOpts)
 	fs := flag.NewFlagSet("", flag.ExitOnError)
-	fs.StringVar(&opts.definitionFile, "definitionFile", "", "file where type is defined (required)")
+	fs.StringVar(&opts.definitionFile, "definitionFile", "", "file where type is defined (required, unless -packageDir is given)")
 	fs.StringVar(&opts.outFile, "outFile", "", "file to write builder option functions to; stdout if omitted (optional)")
-	fs.StringVar(&opts.structTypeName, "structTypeName", "", "fieldName of type to generate builder options for (required)")
+	fs.StringVar(&opts.structTypeName, "structTypeName", "", "fieldName of type to generate builder options for (required, unless -packageDir is given)")
+	fs.StringVar(&opts.packageDir, "packageDir", "", "directory to scan for every struct tagged with the builderopts:generate marker comment (or matching -structTypePattern); alternative to -definitionFile/-structTypeName (optional)")
+	fs.StringVar(&opts.structTypePattern, "structTypePattern", "", "regex; in -packageDir mode, a struct type whose name matches it is included even without the marker comment (optional)")
+	fs.StringVar(&opts.style, "style", "options", `output style: "options" for functional option functions, "builder" for a fluent <T>Builder type (optional)`)
 	fs.BoolVar(&opts.exportFnType, "exportOptionFuncType", true, "whether to export the configuration function type (optional)")
 	fs.BoolVar(&opts.generateForUnexportedFields, "generateForUnexportedFields", false, "whether to generate configuration functions for unexported fields (optional)")
 	fs.BoolVar(&opts.ignoreUnsupported, "ignoreUnsupported", true, "whether to skip fields whose type we can't handle (error otherwise) (optional)")
+	fs.BoolVar(&opts.promoteEmbedded, "promoteEmbedded", false, "whether to generate promoted setters for embedded struct fields instead of skipping/erroring on them (optional)")
 	fs.Var(&opts.skipStructFields, "skipStructFields", "comma-separated list of struct fields to ignore (exact match)")
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		fail(err.Error())
 	}
 
-	if opts.definitionFile == "" || opts.structTypeName == "" {
+	if opts.packageDir == "" {
+		if opts.definitionFile == "" || opts.structTypeName == "" {
+			fs.Usage()
+			fail("")
+		}
+	} else if opts.definitionFile != "" || opts.structTypeName != "" {
 		fs.Usage()
-		fail("")
+		fail("-packageDir cannot be combined with -definitionFile/-structTypeName")
+	}
+
+	if opts.style != "options" && opts.style != "builder" {
+		fs.Usage()
+		fail(`-style must be "options" or "builder"`)
 	}
 
 	return opts
@@ -117,77 +143,333 @@ func main() {
 }
 
 func run(opts *flagOpts) (io.Reader, error) {
-	// Read input file
-	fset := token.NewFileSet()
-	astF, err := parser.ParseFile(fset, opts.definitionFile, nil, 0)
+	if opts.packageDir != "" {
+		return runPackageDir(opts)
+	}
+	return runSingleStruct(opts)
+}
+
+// runSingleStruct is the original mode of operation: generate builder options
+// for the one struct named by opts.structTypeName in opts.definitionFile.
+func runSingleStruct(opts *flagOpts) (io.Reader, error) {
+	// Load and type-check the package containing definitionFile, so fields
+	// whose type is imported or aliased can be resolved properly.
+	pkg, err := checkDefinitionFile(opts.definitionFile)
 	if err != nil {
 		return nil, err
 	}
 
-	// Look for specified struct type.
-	structType, ok := findRequestedStructType(astF, opts.structTypeName)
+	// Look for specified struct type, following it through if it names a
+	// (possibly chained) alias to a struct type.
+	structType, ok := findRequestedStructType(pkg, opts.structTypeName)
 	if !ok {
 		return nil, fmt.Errorf("could not find struct type in definition file")
 	}
 
-	fnTypeIdent := funcTypeIdent(structType.Name.Name, opts.exportFnType)
-	fnParamType := &ast.StarExpr{
-		X: structType.Name,
+	astOut := &ast.File{Name: ast.NewIdent(pkg.Name())}
+	imports := newImportSet(pkg.Path())
+
+	if err := withStructOptions(astOut, opts.structTypeName, structType, opts, imports); err != nil {
+		return nil, err
+	}
+
+	// Add an import block for any packages pulled in by field types or by
+	// supporting code this generator emitted.
+	withImportDecl(astOut, imports)
+
+	// Generate output file
+	out := new(bytes.Buffer)
+	if err := printer.Fprint(out, token.NewFileSet(), astOut); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// runPackageDir generates builder options for every struct type in
+// opts.packageDir whose doc comment carries the builderopts:generate marker,
+// or whose name matches opts.structTypePattern. All generated code lands in a
+// single output file with one deduplicated import block.
+func runPackageDir(opts *flagOpts) (io.Reader, error) {
+	astFiles, pkg, err := checkPackageDir(opts.packageDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var pattern *regexp.Regexp
+	if opts.structTypePattern != "" {
+		pattern, err = regexp.Compile(opts.structTypePattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -structTypePattern: %w", err)
+		}
+	}
+
+	targetNames := findGeneratedStructTypeNames(astFiles, pattern)
+	if len(targetNames) == 0 {
+		return nil, fmt.Errorf("no struct type in %s was tagged with the builderopts:generate marker or matched -structTypePattern", opts.packageDir)
+	}
+
+	astOut := &ast.File{Name: ast.NewIdent(pkg.Name())}
+	imports := newImportSet(pkg.Path())
+
+	for _, name := range targetNames {
+		structType, ok := findRequestedStructType(pkg, name)
+		if !ok {
+			return nil, fmt.Errorf("%s: could not find struct type", name)
+		}
+
+		if err := withStructOptions(astOut, name, structType, opts, imports); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	withImportDecl(astOut, imports)
+
+	out := new(bytes.Buffer)
+	if err := printer.Fprint(out, token.NewFileSet(), astOut); err != nil {
+		return nil, err
 	}
 
-	// Initialize output
-	astOut := &ast.File{Name: astF.Name}
+	return out, nil
+}
+
+// withStructOptions adds the generated code for a single struct type to
+// astOut, in the style requested by opts.style: functional option functions,
+// plus a Validate function when any field is tagged `builder:"required"` (the
+// default, "options"), or a fluent <T>Builder type ("builder").
+func withStructOptions(
+	astOut *ast.File,
+	structTypeName string,
+	structType *types.Struct,
+	opts *flagOpts,
+	imports importSet,
+) error {
+	if opts.style == "builder" {
+		_, err := withBuilderMethods(
+			astOut,
+			structTypeName,
+			structType,
+			opts.generateForUnexportedFields,
+			opts.ignoreUnsupported,
+			opts.skipStructFields,
+			imports)
+		return err
+	}
+
+	fnTypeIdent := funcTypeIdent(structTypeName, opts.exportFnType)
+	fnParamType := &ast.StarExpr{
+		X: ast.NewIdent(structTypeName),
+	}
 
-	// Add type definition for functional option function signature
 	withTypeDef(astOut, fnTypeIdent, fnParamType)
 
-	// Add function for each applicable struct field
-	if err := withFuncs(
+	requiredFields, err := withFuncs(
 		astOut,
+		structTypeName,
 		structType,
 		fnTypeIdent,
 		fnParamType,
 		opts.generateForUnexportedFields,
 		opts.ignoreUnsupported,
-		opts.skipStructFields); err != nil {
-		return nil, err
+		opts.promoteEmbedded,
+		opts.skipStructFields,
+		imports)
+	if err != nil {
+		return err
 	}
 
-	// Generate output file
-	out := new(bytes.Buffer)
-	if err := printer.Fprint(out, token.NewFileSet(), astOut); err != nil {
-		return nil, err
+	// Fields tagged `builder:"required"` get a generated Validate function.
+	if len(requiredFields) > 0 {
+		withValidateFunc(astOut, structTypeName, requiredFields, imports)
 	}
 
-	return out, nil
+	return nil
 }
 
-// findRequestedStructType searches the input file for a struct type with name
-// structName. If found, return the type spec, true; else return nil, false.
-func findRequestedStructType(f *ast.File, structName string) (*ast.TypeSpec, bool) {
-	for _, decl := range f.Decls {
-		genDecl, ok := decl.(*ast.GenDecl)
-		if !ok {
-			continue
-		}
+// packagesLoadMode is the set of go/packages data needed to resolve struct
+// field types - including ones naming another package entirely, whether
+// that's the standard library, a third-party module, or a sibling file in
+// the same package - via go/types.
+const packagesLoadMode = packages.NeedName | packages.NeedImports | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax
 
-		if genDecl.Tok != token.TYPE {
-			continue
+// checkDefinitionFile loads the package containing definitionFile via
+// go/packages and type-checks it, so field types which reference another
+// package (or another file in the same package) can be resolved via
+// go/types instead of raw AST.
+func checkDefinitionFile(definitionFile string) (*types.Package, error) {
+	loadFile := definitionFile
+	if filepath.Ext(loadFile) != ".go" {
+		// go/packages (like the go command itself) only recognizes files
+		// named *.go; testdata fixtures are named *.go.in so the repo's own
+		// build doesn't try to compile them, so load through a temporary
+		// *.go copy instead.
+		tmpFile, err := copyToTempGoFile(definitionFile)
+		if err != nil {
+			return nil, err
 		}
+		defer os.Remove(tmpFile)
+		loadFile = tmpFile
+	}
+
+	pkg, err := loadSinglePackage("file=" + loadFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", definitionFile, err)
+	}
+
+	return pkg.Types, nil
+}
+
+// copyToTempGoFile copies path's contents into a new sibling file named
+// *.go, so go/packages can load it, and returns the new file's path. The
+// caller is responsible for removing it.
+func copyToTempGoFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "*.go")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
 
-		for _, spec := range genDecl.Specs {
-			typeSpec, ok := spec.(*ast.TypeSpec)
-			if !ok {
+	if _, err := tmp.Write(content); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return tmp.Name(), nil
+}
+
+// generateMarker is the doc-comment text, without its leading "//", that
+// opts in a struct type to -packageDir mode.
+const generateMarker = "builderopts:generate"
+
+// checkPackageDir loads dir as a package via go/packages and type-checks it,
+// so -packageDir mode can resolve struct types that reference each other (or
+// another package) across files.
+func checkPackageDir(dir string) ([]*ast.File, *types.Package, error) {
+	// go/packages treats a bare relative directory (e.g. "testdata/pkgdir1")
+	// as an import path rather than a filesystem path; only "./", "../", and
+	// absolute paths are recognized as such. -packageDir accepts any
+	// directory string, so normalize it the same way the go command's own
+	// flags do.
+	loadDir := dir
+	if !filepath.IsAbs(loadDir) && !strings.HasPrefix(loadDir, "./") && !strings.HasPrefix(loadDir, "../") {
+		loadDir = "./" + loadDir
+	}
+
+	pkg, err := loadSinglePackage(loadDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading %s: %w", dir, err)
+	}
+
+	astFiles := append([]*ast.File(nil), pkg.Syntax...)
+	sort.Slice(astFiles, func(i, j int) bool {
+		return pkg.Fset.Position(astFiles[i].Pos()).Filename < pkg.Fset.Position(astFiles[j].Pos()).Filename
+	})
+
+	return astFiles, pkg.Types, nil
+}
+
+// loadSinglePackage runs go/packages.Load for pattern with packagesLoadMode,
+// and requires that it resolve to exactly one error-free package.
+func loadSinglePackage(pattern string) (*packages.Package, error) {
+	pkgs, err := packages.Load(&packages.Config{Mode: packagesLoadMode}, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected exactly one package for %q, found %d", pattern, len(pkgs))
+	}
+
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		return nil, pkg.Errors[0]
+	}
+
+	return pkg, nil
+}
+
+// findGeneratedStructTypeNames scans astFiles for every type spec whose doc
+// comment carries the builderopts:generate marker, or (if pattern is
+// non-nil) whose name matches pattern. The returned names are deduplicated
+// and sorted for deterministic output.
+func findGeneratedStructTypeNames(astFiles []*ast.File, pattern *regexp.Regexp) []string {
+	seen := make(map[string]bool)
+	var names []string
+
+	for _, f := range astFiles {
+		for _, decl := range f.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
 				continue
 			}
 
-			if _, ok := typeSpec.Type.(*ast.StructType); ok && typeSpec.Name.Name == structName {
-				return typeSpec, true
+			for _, spec := range genDecl.Specs {
+				typeSpec, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+
+				doc := typeSpec.Doc
+				if doc == nil {
+					doc = genDecl.Doc
+				}
+
+				marked := hasGenerateMarker(doc)
+				matched := pattern != nil && pattern.MatchString(typeSpec.Name.Name)
+				if !marked && !matched {
+					continue
+				}
+
+				if !seen[typeSpec.Name.Name] {
+					seen[typeSpec.Name.Name] = true
+					names = append(names, typeSpec.Name.Name)
+				}
 			}
 		}
 	}
 
-	return nil, false
+	sort.Strings(names)
+	return names
+}
+
+// hasGenerateMarker reports whether doc carries the builderopts:generate
+// marker. It scans doc.List's raw comment text rather than doc.Text(): the
+// marker's own "//name:rest" shape (no space after "//") is exactly what
+// go/ast treats as a compiler directive, which CommentGroup.Text() silently
+// drops before we'd ever see it.
+func hasGenerateMarker(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.Contains(c.Text, generateMarker) {
+			return true
+		}
+	}
+	return false
+}
+
+// findRequestedStructType searches the type-checked package for a type with
+// name structName and returns its underlying struct type, true, if either:
+//   - structName itself is a struct type, or
+//   - structName is a (possibly chained) `type X = Y` alias whose ultimate
+//     underlying type is a struct.
+//
+// Underlying resolves alias chains to their ultimate target itself, so no
+// explicit chasing is needed here.
+func findRequestedStructType(pkg *types.Package, structName string) (*types.Struct, bool) {
+	obj := pkg.Scope().Lookup(structName)
+	if obj == nil {
+		return nil, false
+	}
+
+	structType, ok := obj.Type().Underlying().(*types.Struct)
+	return structType, ok
 }
 
 // funcTypeIdent returns the identifier for the name of the functional option
@@ -229,118 +511,609 @@ func withTypeDef(astOut *ast.File, fnIdent *ast.Ident, paramType *ast.StarExpr)
 	})
 }
 
-// withFuncs creates a functional option function for each applicable field and
-// adds it to astOut.
-func withFuncs(
+// importSet tracks the set of import paths referenced by generated code -
+// both field types pulled in via typeExpr, and packages used directly by
+// supporting code this generator emits (e.g. "reflect" for Validate) - so
+// that a correct import block can be emitted for astOut. selfPkgPath is the
+// path of the package being generated into, so qualifier can recognize a
+// same-package reference and avoid emitting a self-import.
+type importSet struct {
+	selfPkgPath string
+	paths       map[string]struct{}
+}
+
+// newImportSet returns an empty importSet for code being generated into the
+// package at selfPkgPath.
+func newImportSet(selfPkgPath string) importSet {
+	return importSet{selfPkgPath: selfPkgPath, paths: make(map[string]struct{})}
+}
+
+// add records path as an import the generated code needs.
+func (s importSet) add(path string) {
+	s.paths[path] = struct{}{}
+}
+
+// qualifier is a types.Qualifier that records pkg's path in s and returns the
+// identifier generated code should use to refer to it. It returns "" for the
+// package being generated into itself, the same as it already does for pkg ==
+// nil, so a field or embed of a type declared alongside the target struct
+// isn't qualified (and self-imported) as if it came from another package.
+func (s importSet) qualifier(pkg *types.Package) string {
+	if pkg == nil || pkg.Path() == "" || pkg.Path() == s.selfPkgPath {
+		return ""
+	}
+	s.add(pkg.Path())
+	return pkg.Name()
+}
+
+// withImportDecl prepends an import declaration covering every package in
+// imports to astOut's declarations. It is a no-op if imports is empty.
+func withImportDecl(astOut *ast.File, imports importSet) {
+	if len(imports.paths) == 0 {
+		return
+	}
+
+	paths := make([]string, 0, len(imports.paths))
+	for path := range imports.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	specs := make([]ast.Spec, 0, len(paths))
+	for _, path := range paths {
+		specs = append(specs, &ast.ImportSpec{
+			Path: &ast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf("%q", path)},
+		})
+	}
+
+	importDecl := &ast.GenDecl{
+		Tok:    token.IMPORT,
+		Lparen: token.Pos(1), // any valid pos; tells the printer to use "import (...)"
+		Specs:  specs,
+	}
+
+	astOut.Decls = append([]ast.Decl{importDecl}, astOut.Decls...)
+}
+
+// fieldDirectives captures the per-field generation policy read from a
+// `builder:"..."` struct tag, letting a field override or refine the
+// CLI-level flags for itself.
+type fieldDirectives struct {
+	skip     bool
+	name     string
+	fnType   string
+	required bool
+}
+
+// parseFieldDirectives parses the comma-separated builder:"..." struct tag on
+// a field. Recognized directives are "-" (skip the field), "name=Foo"
+// (override the generated setter name), "fn=Foo" (override the functional
+// option type used for this field only), and "required" (track the field for
+// the generated Validate function).
+func parseFieldDirectives(tag string) fieldDirectives {
+	var d fieldDirectives
+
+	raw, ok := reflect.StructTag(tag).Lookup("builder")
+	if !ok {
+		return d
+	}
+
+	for _, part := range strings.Split(raw, ",") {
+		switch {
+		case part == "-":
+			d.skip = true
+		case part == "required":
+			d.required = true
+		case strings.HasPrefix(part, "name="):
+			d.name = strings.TrimPrefix(part, "name=")
+		case strings.HasPrefix(part, "fn="):
+			d.fnType = strings.TrimPrefix(part, "fn=")
+		}
+	}
+
+	return d
+}
+
+// discoveredField is a non-embedded struct field that withFuncs or
+// withBuilderMethods has decided to generate code for, after applying the
+// CLI skip list, `builder:"..."` tag directives, and export rules.
+type discoveredField struct {
+	name        string     // the field's actual name in the struct
+	setterName  string     // field.name, or a builder:"name=..." override
+	typeExpr    ast.Expr   // reconstructed type expression
+	fnTypeIdent *ast.Ident // -style=options only: the functional option type to use
+}
+
+// discoverFields walks structType's non-embedded fields and returns the ones
+// code should be generated for, alongside the names of any fields tagged
+// `builder:"required"`. Embedded fields are left to the caller - withFuncs
+// handles them itself via withPromotedEmbeddedFuncs, and -style=builder does
+// not support promotion.
+func discoverFields(
 	astOut *ast.File,
-	structType *ast.TypeSpec,
+	structType *types.Struct,
 	fnIdent *ast.Ident,
 	fnParamType *ast.StarExpr,
 	generateForUnexportedFields, ignoreUnsupported bool,
 	skipStructFields map[string]struct{},
-) error {
-	structTypeTyped, ok := structType.Type.(*ast.StructType)
-	if !ok {
-		panic("bad type for struct type")
-	}
+	imports importSet,
+) ([]discoveredField, []string, error) {
+	var fields []discoveredField
+	var requiredFields []string
+	fnTypesEmitted := make(map[string]bool)
 
-	var numFnsAdded int
+	for i := 0; i < structType.NumFields(); i++ {
+		field := structType.Field(i)
+		if field.Embedded() {
+			continue
+		}
 
-	// Look at fields. Each entry in list is actually a list: could be embedded
-	// field (length 0), "regular" field (length 1), or multiple named fields
-	// with same type (length > 1).
-	for _, field := range structTypeTyped.Fields.List {
+		if _, ok := skipStructFields[field.Name()]; ok {
+			continue
+		}
 
-		// No embedded fields
-		if len(field.Names) == 0 {
+		directives := parseFieldDirectives(structType.Tag(i))
+		if directives.skip {
+			continue
+		}
+
+		if !field.Exported() && !generateForUnexportedFields {
+			continue
+		}
+
+		fieldTypeExpr, err := typeExpr(field.Type(), imports)
+		if err != nil {
 			if ignoreUnsupported {
 				continue
-			} else {
-				return fmt.Errorf("embedded fields disallowed")
 			}
+			return nil, nil, err
 		}
 
-		// No fields whose type is imported from another package
-		var fieldContainsImport bool
-		ast.Inspect(field, func(n ast.Node) bool {
-			_, ok := n.(*ast.SelectorExpr)
-			if ok {
-				fieldContainsImport = true
-				return false
+		setterName := field.Name()
+		if directives.name != "" {
+			setterName = directives.name
+		}
+
+		fieldFnIdent := fnIdent
+		if fnIdent != nil && directives.fnType != "" {
+			fieldFnIdent = ast.NewIdent(directives.fnType)
+			if !fnTypesEmitted[directives.fnType] {
+				withTypeDef(astOut, fieldFnIdent, fnParamType)
+				fnTypesEmitted[directives.fnType] = true
 			}
-			return true
+		}
+
+		if directives.required {
+			requiredFields = append(requiredFields, field.Name())
+		}
+
+		fields = append(fields, discoveredField{
+			name:        field.Name(),
+			setterName:  setterName,
+			typeExpr:    fieldTypeExpr,
+			fnTypeIdent: fieldFnIdent,
 		})
-		if fieldContainsImport {
+	}
+
+	return fields, requiredFields, nil
+}
+
+// withFuncs creates a functional option function for each applicable field and
+// adds it to astOut. It returns the names of any fields tagged
+// `builder:"required"`.
+func withFuncs(
+	astOut *ast.File,
+	structTypeName string,
+	structType *types.Struct,
+	fnIdent *ast.Ident,
+	fnParamType *ast.StarExpr,
+	generateForUnexportedFields, ignoreUnsupported, promoteEmbedded bool,
+	skipStructFields map[string]struct{},
+	imports importSet,
+) ([]string, error) {
+	var numFnsAdded int
+	var promotedRequiredFields []string
+
+	outerNames := make(map[string]bool)
+	for i := 0; i < structType.NumFields(); i++ {
+		if f := structType.Field(i); !f.Embedded() {
+			outerNames[f.Name()] = true
+		}
+	}
+	promotedNames := make(map[string]string)
+
+	for i := 0; i < structType.NumFields(); i++ {
+		field := structType.Field(i)
+		if !field.Embedded() {
+			continue
+		}
+
+		if !promoteEmbedded {
 			if ignoreUnsupported {
 				continue
-			} else {
-				return fmt.Errorf("cannot generate for fields whose type is imported")
 			}
+			return nil, fmt.Errorf("embedded fields disallowed")
 		}
 
-		// Now that we're operating on non-imported types and non-embedded
-		// fields, let's look at each actual field name and generate a setter
-		// for it.
-		for _, fieldName := range field.Names {
+		added, promotedRequired, err := withPromotedEmbeddedFuncs(
+			astOut,
+			structTypeName,
+			field,
+			fnIdent,
+			fnParamType,
+			generateForUnexportedFields,
+			ignoreUnsupported,
+			skipStructFields,
+			outerNames,
+			promotedNames,
+			imports)
+		if err != nil {
+			return nil, err
+		}
+		numFnsAdded += added
+		promotedRequiredFields = append(promotedRequiredFields, promotedRequired...)
+	}
 
-			if _, ok := skipStructFields[fieldName.Name]; ok {
-				continue
-			}
+	fields, requiredFields, err := discoverFields(
+		astOut,
+		structType,
+		fnIdent,
+		fnParamType,
+		generateForUnexportedFields,
+		ignoreUnsupported,
+		skipStructFields,
+		imports)
+	if err != nil {
+		return nil, err
+	}
 
-			if unicode.IsLower(rune(fieldName.Name[0])) && !generateForUnexportedFields {
-				continue
-			}
+	for _, field := range fields {
+		outerParamIdent := ast.NewIdent(withFirstCharLower(field.name) + "Gen")
+		newFunc := &ast.FuncDecl{
+			Name: ast.NewIdent(setterName("Set", field.setterName, structTypeName)),
+			Type: &ast.FuncType{
+				Params: &ast.FieldList{
+					List: []*ast.Field{
+						{
+							Names: []*ast.Ident{outerParamIdent},
+							Type:  field.typeExpr,
+						},
+					},
+				},
+				Results: &ast.FieldList{
+					List: []*ast.Field{{Type: field.fnTypeIdent}},
+				},
+			},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.ReturnStmt{
+						Results: []ast.Expr{
+							getInnerFn(
+								structTypeName,
+								field.name,
+								outerParamIdent,
+								fnParamType,
+							),
+						},
+					},
+				},
+			},
+		}
+		astOut.Decls = append(astOut.Decls, newFunc)
+		numFnsAdded++
+	}
+
+	if numFnsAdded == 0 {
+		return nil, fmt.Errorf("no fields in struct (aside from ignored errors)")
+	}
+
+	return append(requiredFields, promotedRequiredFields...), nil
+}
+
+// withBuilderMethods generates a fluent <T>Builder type for -style=builder: a
+// struct wrapping a *T target, a New<T>Builder constructor, one chainable
+// With<Field> method per discovered field, and a terminal Build method. It
+// returns the names of any fields tagged `builder:"required"`.
+//
+// Unlike withFuncs, it does not support -promoteEmbedded: embedded fields are
+// always skipped (or rejected, per ignoreUnsupported), since a chainable
+// With<Field> method can't distinguish which embedded type a promoted field
+// name came from as cleanly as a free Set<Embedded><Field> function can.
+func withBuilderMethods(
+	astOut *ast.File,
+	structTypeName string,
+	structType *types.Struct,
+	generateForUnexportedFields, ignoreUnsupported bool,
+	skipStructFields map[string]struct{},
+	imports importSet,
+) ([]string, error) {
+	for i := 0; i < structType.NumFields(); i++ {
+		if structType.Field(i).Embedded() && !ignoreUnsupported {
+			return nil, fmt.Errorf("embedded fields disallowed")
+		}
+	}
+
+	fields, requiredFields, err := discoverFields(
+		astOut,
+		structType,
+		nil,
+		nil,
+		generateForUnexportedFields,
+		ignoreUnsupported,
+		skipStructFields,
+		imports)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields in struct (aside from ignored errors)")
+	}
+
+	builderName := structTypeName + "Builder"
+	builderIdent := ast.NewIdent(builderName)
+	builderPtrType := &ast.StarExpr{X: ast.NewIdent(builderName)}
+	targetPtrType := &ast.StarExpr{X: ast.NewIdent(structTypeName)}
+	receiverIdent := ast.NewIdent("b")
 
-			outerParamIdent := ast.NewIdent(withFirstCharLower(fieldName.Name) + "Gen")
-			newFunc := &ast.FuncDecl{
-				Name: ast.NewIdent("Set" + withFirstCharUppper(fieldName.Name)),
-				Type: &ast.FuncType{
-					Params: &ast.FieldList{
+	astOut.Decls = append(astOut.Decls, &ast.GenDecl{
+		Tok: token.TYPE,
+		Specs: []ast.Spec{
+			&ast.TypeSpec{
+				Name: builderIdent,
+				Type: &ast.StructType{
+					Fields: &ast.FieldList{
 						List: []*ast.Field{
-							{
-								Names: []*ast.Ident{outerParamIdent},
-								Type:  field.Type,
+							{Names: []*ast.Ident{ast.NewIdent("target")}, Type: targetPtrType},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	astOut.Decls = append(astOut.Decls, &ast.FuncDecl{
+		Name: ast.NewIdent("New" + builderName),
+		Type: &ast.FuncType{
+			Results: &ast.FieldList{List: []*ast.Field{{Type: builderPtrType}}},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.ReturnStmt{
+					Results: []ast.Expr{
+						&ast.UnaryExpr{
+							Op: token.AND,
+							X: &ast.CompositeLit{
+								Type: builderIdent,
+								Elts: []ast.Expr{
+									&ast.KeyValueExpr{
+										Key: ast.NewIdent("target"),
+										Value: &ast.UnaryExpr{
+											Op: token.AND,
+											X:  &ast.CompositeLit{Type: ast.NewIdent(structTypeName)},
+										},
+									},
+								},
 							},
 						},
 					},
-					Results: &ast.FieldList{
-						List: []*ast.Field{{Type: fnIdent}},
+				},
+			},
+		},
+	})
+
+	for _, field := range fields {
+		outerParamIdent := ast.NewIdent(withFirstCharLower(field.name) + "Gen")
+		astOut.Decls = append(astOut.Decls, &ast.FuncDecl{
+			Recv: &ast.FieldList{
+				List: []*ast.Field{{Names: []*ast.Ident{receiverIdent}, Type: builderPtrType}},
+			},
+			Name: ast.NewIdent(setterName("With", field.setterName, structTypeName)),
+			Type: &ast.FuncType{
+				Params: &ast.FieldList{
+					List: []*ast.Field{{Names: []*ast.Ident{outerParamIdent}, Type: field.typeExpr}},
+				},
+				Results: &ast.FieldList{List: []*ast.Field{{Type: builderPtrType}}},
+			},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.AssignStmt{
+						Lhs: []ast.Expr{
+							&ast.SelectorExpr{
+								X:   &ast.SelectorExpr{X: receiverIdent, Sel: ast.NewIdent("target")},
+								Sel: ast.NewIdent(field.name),
+							},
+						},
+						Tok: token.ASSIGN,
+						Rhs: []ast.Expr{outerParamIdent},
+					},
+					&ast.ReturnStmt{Results: []ast.Expr{receiverIdent}},
+				},
+			},
+		})
+	}
+
+	if len(requiredFields) > 0 {
+		withRequiredBuildMethod(astOut, structTypeName, builderPtrType, receiverIdent, requiredFields, imports)
+	} else {
+		astOut.Decls = append(astOut.Decls, &ast.FuncDecl{
+			Recv: &ast.FieldList{
+				List: []*ast.Field{{Names: []*ast.Ident{receiverIdent}, Type: builderPtrType}},
+			},
+			Name: ast.NewIdent("Build"),
+			Type: &ast.FuncType{
+				Results: &ast.FieldList{List: []*ast.Field{{Type: targetPtrType}}},
+			},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.ReturnStmt{
+						Results: []ast.Expr{&ast.SelectorExpr{X: receiverIdent, Sel: ast.NewIdent("target")}},
+					},
+				},
+			},
+		})
+	}
+
+	return requiredFields, nil
+}
+
+// withRequiredBuildMethod adds the terminal Build method for a builder whose
+// struct has fields tagged `builder:"required"`: it returns (T, error),
+// reporting an error via the same reflect.ValueOf(...).IsZero() check
+// withValidateFunc uses, instead of the plain Build() *T used when there's
+// nothing to validate.
+func withRequiredBuildMethod(
+	astOut *ast.File,
+	structTypeName string,
+	builderPtrType *ast.StarExpr,
+	receiverIdent *ast.Ident,
+	requiredFields []string,
+	imports importSet,
+) {
+	imports.add("reflect")
+	imports.add("fmt")
+
+	targetSel := &ast.SelectorExpr{X: receiverIdent, Sel: ast.NewIdent("target")}
+
+	var body []ast.Stmt
+	for _, fieldName := range requiredFields {
+		body = append(body, &ast.IfStmt{
+			Cond: &ast.CallExpr{
+				Fun: &ast.SelectorExpr{
+					X: &ast.CallExpr{
+						Fun: &ast.SelectorExpr{X: ast.NewIdent("reflect"), Sel: ast.NewIdent("ValueOf")},
+						Args: []ast.Expr{
+							&ast.SelectorExpr{X: targetSel, Sel: ast.NewIdent(fieldName)},
+						},
 					},
+					Sel: ast.NewIdent("IsZero"),
 				},
-				Body: &ast.BlockStmt{
-					List: []ast.Stmt{
-						&ast.ReturnStmt{
-							Results: []ast.Expr{
-								getInnerFn(
-									structType.Name,
-									fieldName,
-									outerParamIdent,
-									fnParamType,
-								),
+			},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.ReturnStmt{
+						Results: []ast.Expr{
+							&ast.CompositeLit{Type: ast.NewIdent(structTypeName)},
+							&ast.CallExpr{
+								Fun: &ast.SelectorExpr{X: ast.NewIdent("fmt"), Sel: ast.NewIdent("Errorf")},
+								Args: []ast.Expr{
+									&ast.BasicLit{
+										Kind:  token.STRING,
+										Value: fmt.Sprintf("%q", "field "+fieldName+" is required"),
+									},
+								},
 							},
 						},
 					},
 				},
-			}
-			astOut.Decls = append(astOut.Decls, newFunc)
-			numFnsAdded++
-		}
+			},
+		})
 	}
+	body = append(body, &ast.ReturnStmt{
+		Results: []ast.Expr{&ast.StarExpr{X: targetSel}, ast.NewIdent("nil")},
+	})
 
-	if numFnsAdded == 0 {
-		return fmt.Errorf("no fields in struct (aside from ignored errors)")
+	astOut.Decls = append(astOut.Decls, &ast.FuncDecl{
+		Recv: &ast.FieldList{
+			List: []*ast.Field{{Names: []*ast.Ident{receiverIdent}, Type: builderPtrType}},
+		},
+		Name: ast.NewIdent("Build"),
+		Type: &ast.FuncType{
+			Results: &ast.FieldList{
+				List: []*ast.Field{
+					{Type: ast.NewIdent(structTypeName)},
+					{Type: ast.NewIdent("error")},
+				},
+			},
+		},
+		Body: &ast.BlockStmt{List: body},
+	})
+}
+
+// withValidateFunc adds a Validate<StructTypeName> function to astOut that
+// reports an error if any of requiredFields still has its zero value, and
+// records the imports ("reflect", "fmt") that function needs. The struct
+// type name is part of the function name so that -packageDir mode, which can
+// emit one of these per struct, doesn't collide.
+func withValidateFunc(astOut *ast.File, structTypeName string, requiredFields []string, imports importSet) {
+	imports.add("reflect")
+	imports.add("fmt")
+
+	paramIdent := ast.NewIdent(withFirstCharLower(structTypeName) + "Gen")
+
+	var body []ast.Stmt
+	for _, fieldName := range requiredFields {
+		body = append(body, &ast.IfStmt{
+			Cond: &ast.CallExpr{
+				Fun: &ast.SelectorExpr{
+					X: &ast.CallExpr{
+						Fun: &ast.SelectorExpr{X: ast.NewIdent("reflect"), Sel: ast.NewIdent("ValueOf")},
+						Args: []ast.Expr{
+							&ast.SelectorExpr{X: paramIdent, Sel: ast.NewIdent(fieldName)},
+						},
+					},
+					Sel: ast.NewIdent("IsZero"),
+				},
+			},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.ReturnStmt{
+						Results: []ast.Expr{
+							&ast.CallExpr{
+								Fun: &ast.SelectorExpr{X: ast.NewIdent("fmt"), Sel: ast.NewIdent("Errorf")},
+								Args: []ast.Expr{
+									&ast.BasicLit{
+										Kind:  token.STRING,
+										Value: fmt.Sprintf("%q", "field "+fieldName+" is required"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
 	}
+	body = append(body, &ast.ReturnStmt{Results: []ast.Expr{ast.NewIdent("nil")}})
 
-	return nil
+	astOut.Decls = append(astOut.Decls, &ast.FuncDecl{
+		Name: ast.NewIdent("Validate" + withFirstCharUppper(structTypeName)),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{
+				List: []*ast.Field{
+					{
+						Names: []*ast.Ident{paramIdent},
+						Type:  &ast.StarExpr{X: ast.NewIdent(structTypeName)},
+					},
+				},
+			},
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: ast.NewIdent("error")}},
+			},
+		},
+		Body: &ast.BlockStmt{List: body},
+	})
+}
+
+// typeExpr reconstructs the go/ast expression used to spell a field's type in
+// generated source from its go/types.Type, recording any package it
+// references in imports so a correct import block can be emitted.
+func typeExpr(t types.Type, imports importSet) (ast.Expr, error) {
+	str := types.TypeString(t, imports.qualifier)
+	expr, err := parser.ParseExpr(str)
+	if err != nil {
+		return nil, fmt.Errorf("could not reconstruct type expression for %q: %w", str, err)
+	}
+	return expr, nil
 }
 
 // getInnerFn returns a function literal for the inner function - the one that
 // does the assignment of the struct field.
 func getInnerFn(
-	structTypeIdent, fieldIdent, outerParamIdent *ast.Ident,
+	structTypeName, fieldName string,
+	outerParamIdent *ast.Ident,
 	innerParamType *ast.StarExpr,
 ) *ast.FuncLit {
-	paramIdent := ast.NewIdent(withFirstCharLower(structTypeIdent.Name) + "Gen")
+	paramIdent := ast.NewIdent(withFirstCharLower(structTypeName) + "Gen")
 	return &ast.FuncLit{
 		Type: &ast.FuncType{
 			Params: &ast.FieldList{
@@ -358,7 +1131,7 @@ func getInnerFn(
 					Lhs: []ast.Expr{
 						&ast.SelectorExpr{
 							X:   paramIdent,
-							Sel: fieldIdent,
+							Sel: ast.NewIdent(fieldName),
 						},
 					},
 					Tok: token.ASSIGN,
@@ -371,16 +1144,235 @@ func getInnerFn(
 	}
 }
 
+// embeddedStructType unwraps an embedded field's type down to its struct
+// type. It reports whether the field was embedded by pointer, and returns
+// the named type itself (needed to lazily allocate a nil pointer embed).
+func embeddedStructType(t types.Type) (structType *types.Struct, named *types.Named, isPtr, ok bool) {
+	if ptr, isP := t.(*types.Pointer); isP {
+		t = ptr.Elem()
+		isPtr = isP
+	}
+
+	named, ok = t.(*types.Named)
+	if !ok {
+		return nil, nil, isPtr, false
+	}
+
+	structType, ok = named.Underlying().(*types.Struct)
+	return structType, named, isPtr, ok
+}
+
+// withPromotedEmbeddedFuncs generates promoted setters for an embedded
+// struct field's exported fields (and its unexported ones too, when
+// generateForUnexportedFields is set) - for an embedded Inner field X, it emits
+// SetInnerX - and returns how many were added, alongside the names of any
+// promoted fields tagged `builder:"required"`. A promoted field honors the
+// same CLI skip list and `builder:"..."` tag directives discoverFields
+// applies to direct fields. It errors if a promoted field name collides
+// with an outer-struct field name or with another embedded field's
+// promoted name, mirroring Go's own ambiguous-selector diagnostic.
+func withPromotedEmbeddedFuncs(
+	astOut *ast.File,
+	structTypeName string,
+	embeddedField *types.Var,
+	fnIdent *ast.Ident,
+	fnParamType *ast.StarExpr,
+	generateForUnexportedFields, ignoreUnsupported bool,
+	skipStructFields map[string]struct{},
+	outerNames map[string]bool,
+	promotedNames map[string]string,
+	imports importSet,
+) (int, []string, error) {
+	embeddedType, embeddedNamed, isPtr, ok := embeddedStructType(embeddedField.Type())
+	if !ok {
+		if ignoreUnsupported {
+			return 0, nil, nil
+		}
+		return 0, nil, fmt.Errorf("embedded field %s is not a struct", embeddedField.Name())
+	}
+
+	embeddedName := embeddedField.Name()
+
+	var embeddedTypeExpr ast.Expr
+	if isPtr {
+		var err error
+		embeddedTypeExpr, err = typeExpr(embeddedNamed, imports)
+		if err != nil {
+			if ignoreUnsupported {
+				return 0, nil, nil
+			}
+			return 0, nil, err
+		}
+	}
+
+	var numAdded int
+	var requiredFields []string
+	for i := 0; i < embeddedType.NumFields(); i++ {
+		field := embeddedType.Field(i)
+
+		// Promotion only recurses one level deep; a struct embedded within
+		// an embedded struct is left alone.
+		if field.Embedded() {
+			continue
+		}
+
+		if _, ok := skipStructFields[field.Name()]; ok {
+			continue
+		}
+
+		directives := parseFieldDirectives(embeddedType.Tag(i))
+		if directives.skip {
+			continue
+		}
+
+		if !field.Exported() && !generateForUnexportedFields {
+			continue
+		}
+
+		if outerNames[field.Name()] {
+			return 0, nil, fmt.Errorf("ambiguous selector %s.%s: declared directly and promoted from embedded %s",
+				structTypeName, field.Name(), embeddedName)
+		}
+		if other, ok := promotedNames[field.Name()]; ok && other != embeddedName {
+			return 0, nil, fmt.Errorf("ambiguous selector %s.%s: promoted from both embedded %s and embedded %s",
+				structTypeName, field.Name(), other, embeddedName)
+		}
+		promotedNames[field.Name()] = embeddedName
+
+		fieldTypeExpr, err := typeExpr(field.Type(), imports)
+		if err != nil {
+			if ignoreUnsupported {
+				continue
+			}
+			return 0, nil, err
+		}
+
+		setterField := embeddedName + withFirstCharUppper(field.Name())
+		if directives.name != "" {
+			setterField = directives.name
+		}
+
+		if directives.required {
+			requiredFields = append(requiredFields, field.Name())
+		}
+
+		outerParamIdent := ast.NewIdent(withFirstCharLower(field.Name()) + "Gen")
+		newFunc := &ast.FuncDecl{
+			Name: ast.NewIdent(setterName("Set", setterField, structTypeName)),
+			Type: &ast.FuncType{
+				Params: &ast.FieldList{
+					List: []*ast.Field{
+						{
+							Names: []*ast.Ident{outerParamIdent},
+							Type:  fieldTypeExpr,
+						},
+					},
+				},
+				Results: &ast.FieldList{
+					List: []*ast.Field{{Type: fnIdent}},
+				},
+			},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.ReturnStmt{
+						Results: []ast.Expr{
+							getPromotedInnerFn(
+								structTypeName,
+								embeddedName,
+								field.Name(),
+								isPtr,
+								embeddedTypeExpr,
+								outerParamIdent,
+								fnParamType,
+							),
+						},
+					},
+				},
+			},
+		}
+		astOut.Decls = append(astOut.Decls, newFunc)
+		numAdded++
+	}
+
+	return numAdded, requiredFields, nil
+}
+
+// getPromotedInnerFn returns the function literal for a promoted embedded
+// field setter. When the embedded field is itself a pointer, it's lazily
+// allocated if nil before the assignment.
+func getPromotedInnerFn(
+	structTypeName, embeddedName, fieldName string,
+	embeddedIsPtr bool,
+	embeddedTypeExpr ast.Expr,
+	outerParamIdent *ast.Ident,
+	innerParamType *ast.StarExpr,
+) *ast.FuncLit {
+	paramIdent := ast.NewIdent(withFirstCharLower(structTypeName) + "Gen")
+	embeddedSel := &ast.SelectorExpr{X: paramIdent, Sel: ast.NewIdent(embeddedName)}
+
+	var body []ast.Stmt
+	if embeddedIsPtr {
+		body = append(body, &ast.IfStmt{
+			Cond: &ast.BinaryExpr{X: embeddedSel, Op: token.EQL, Y: ast.NewIdent("nil")},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.AssignStmt{
+						Lhs: []ast.Expr{embeddedSel},
+						Tok: token.ASSIGN,
+						Rhs: []ast.Expr{
+							&ast.UnaryExpr{Op: token.AND, X: &ast.CompositeLit{Type: embeddedTypeExpr}},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	body = append(body, &ast.AssignStmt{
+		Lhs: []ast.Expr{&ast.SelectorExpr{X: embeddedSel, Sel: ast.NewIdent(fieldName)}},
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{outerParamIdent},
+	})
+
+	return &ast.FuncLit{
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{
+				List: []*ast.Field{
+					{
+						Names: []*ast.Ident{paramIdent},
+						Type:  innerParamType,
+					},
+				},
+			},
+		},
+		Body: &ast.BlockStmt{List: body},
+	}
+}
+
+// setterName returns prefix + name, with name's first rune upper-cased and
+// prefix's first rune cased to match structTypeName's own export-ness: if
+// structTypeName is unexported, the result is forced unexported too (e.g.
+// "Set" + "Foo" -> "setFoo"), even when name itself is exported. Otherwise, a setter for an unexported struct type
+// would itself end up exported, which go vet and golint both flag.
+func setterName(prefix, name, structTypeName string) string {
+	if ast.IsExported(structTypeName) {
+		return prefix + withFirstCharUppper(name)
+	}
+	return withFirstCharLower(prefix) + withFirstCharUppper(name)
+}
+
 func withFirstCharLower(s string) string {
-	if len(s) == 0 {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
 		return s
 	}
-	return strings.ToLower(s[0:1]) + s[1:]
+	return string(unicode.ToLower(r)) + s[size:]
 }
 
 func withFirstCharUppper(s string) string {
-	if len(s) == 0 {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
 		return s
 	}
-	return strings.ToUpper(s[0:1]) + s[1:]
+	return string(unicode.ToUpper(r)) + s[size:]
 }
@@ -0,0 +1,8 @@
+package pkgdir1
+
+// Widget is a sample exported type.
+//
+//builderopts:generate
+type Widget struct {
+	Name string
+}
@@ -0,0 +1,9 @@
+package pkgdir1
+
+type Gadget struct {
+	Size int
+}
+
+type Helper struct {
+	Unused bool
+}